@@ -0,0 +1,395 @@
+// Package grpc provides functions to trace the google.golang.org/grpc package v1.2.
+package grpc
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	tagMethod         = "grpc.method"
+	tagCode           = "grpc.code"
+	tagMetadataPrefix = "grpc.metadata."
+	tagPeerAddress    = "peer.address"
+	tagPeerHostname   = "peer.hostname"
+)
+
+// metadataCarrier adapts grpc metadata.MD to the tracer.TextMapWriter and
+// tracer.TextMapReader interfaces so that a span context can be injected
+// into, or extracted from, the metadata exchanged between a gRPC client and
+// server.
+type metadataCarrier struct {
+	metadata.MD
+}
+
+// Set implements tracer.TextMapWriter.
+func (c metadataCarrier) Set(key, val string) {
+	c.MD[key] = append(c.MD[key], val)
+}
+
+// ForeachKey implements tracer.TextMapReader.
+func (c metadataCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, vs := range c.MD {
+		for _, v := range vs {
+			if err := handler(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UnaryClientInterceptor will add tracing to a client unary request.
+func UnaryClientInterceptor(opts ...InterceptorOption) grpc.UnaryClientInterceptor {
+	cfg := new(interceptorConfig)
+	defaults(cfg)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		span, ctx := startClientSpan(ctx, cfg, method, cc.Target())
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		finishClientSpan(span, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor will add tracing to a client streaming request.
+func StreamClientInterceptor(opts ...InterceptorOption) grpc.StreamClientInterceptor {
+	cfg := new(interceptorConfig)
+	defaults(cfg)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span, ctx := startClientSpan(ctx, cfg, method, cc.Target())
+		s, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			finishClientSpan(span, err)
+			return s, err
+		}
+		cs := &clientStream{
+			ClientStream: s,
+			cfg:          cfg,
+			method:       method,
+			callSpan:     span,
+		}
+		if !cfg.traceStreamCalls {
+			finishClientSpan(span, nil)
+			cs.finished = true
+			cs.callSpan = nil
+		} else {
+			// SendMsg/RecvMsg only observe the stream ending through an
+			// error, so a caller that cancels ctx and then simply stops
+			// calling either (a common abandon-the-stream pattern) would
+			// otherwise leak the call span forever. Watch ctx's Done() too
+			// and finish the span from whichever happens first.
+			//
+			// This deliberately watches the interceptor's own ctx, not
+			// s.Context(): the latter is a per-attempt context that grpc-go
+			// cancels internally as part of tearing down *every* stream,
+			// success or failure, which would race finishCallSpan's normal
+			// SendMsg/RecvMsg path on every RPC. ctx is only ever canceled
+			// by the caller, so its Done() firing really does mean the
+			// stream was abandoned.
+			go func() {
+				<-ctx.Done()
+				cs.finishCallSpan(ctx.Err())
+			}()
+		}
+		return cs, nil
+	}
+}
+
+// startClientSpan starts the "grpc.client" span for a request to the given
+// method, injecting the active span context into ctx's outgoing metadata so
+// that the server can continue the trace.
+func startClientSpan(ctx context.Context, cfg *interceptorConfig, method, target string) (ddtrace.Span, context.Context) {
+	host, port := splitHostPort(target)
+	span, ctx := tracer.StartSpanFromContext(ctx, "grpc.client",
+		tracer.ServiceName(cfg.serviceName),
+		tracer.ResourceName(method),
+		tracer.SpanType(ext.AppTypeRPC),
+		tracer.Tag(tagMethod, method),
+		tracer.Tag(ext.TargetHost, host),
+		tracer.Tag(ext.TargetPort, port),
+	)
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		tagMetadata(span, cfg.metadataTags, md)
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	if err := tracer.Inject(span.Context(), metadataCarrier{md}); err != nil {
+		// this should never happen
+		span.SetTag(ext.Error, err)
+	}
+	return span, metadata.NewOutgoingContext(ctx, md)
+}
+
+// tagMetadata records the values of the given metadata keys as
+// "grpc.metadata.<key>" tags on span.
+func tagMetadata(span ddtrace.Span, keys []string, md metadata.MD) {
+	for _, key := range keys {
+		if vs := md.Get(key); len(vs) > 0 {
+			span.SetTag(tagMetadataPrefix+key, strings.Join(vs, ","))
+		}
+	}
+}
+
+func finishClientSpan(span ddtrace.Span, err error) {
+	code := status.Code(err)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// status.Code only understands errors that implement GRPCStatus();
+		// a plain context error (as finishes the call span when ctx's
+		// Done() fires ahead of a SendMsg/RecvMsg error) doesn't, and would
+		// otherwise be misreported as codes.Unknown.
+		code = status.FromContextError(err).Code()
+	}
+	span.SetTag(tagCode, code.String())
+	if err != nil && code != codes.Canceled {
+		span.SetTag(ext.Error, err)
+	}
+	span.Finish()
+}
+
+func splitHostPort(target string) (host, port string) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return target, ""
+	}
+	return host, port
+}
+
+// clientStream wraps a grpc.ClientStream so that the call span covering its
+// whole lifetime, and the per-message spans for each Send/Recv, can be
+// recorded.
+type clientStream struct {
+	grpc.ClientStream
+	cfg    *interceptorConfig
+	method string
+
+	mu       sync.Mutex
+	finished bool
+	callSpan ddtrace.Span
+}
+
+// SendMsg finishes the call span synchronously, in the same goroutine as the
+// caller, the moment it observes the stream is done (a send error). A
+// context.Done() watcher (started in StreamClientInterceptor) covers the
+// case where the caller abandons the stream after cancellation instead of
+// observing an error from Send/RecvMsg; finishCallSpan is safe to call from
+// both places.
+func (cs *clientStream) SendMsg(m interface{}) error {
+	err := cs.ClientStream.SendMsg(m)
+	if cs.cfg.traceStreamMessages {
+		cs.traceMessage(err)
+	}
+	if err != nil {
+		cs.finishCallSpan(err)
+	}
+	return err
+}
+
+// RecvMsg finishes the call span synchronously once it observes the stream
+// has ended: io.EOF for a clean server-streaming/bidi close, or any other
+// non-nil error carrying the final status.
+func (cs *clientStream) RecvMsg(m interface{}) error {
+	err := cs.ClientStream.RecvMsg(m)
+	if cs.cfg.traceStreamMessages {
+		cs.traceMessage(err)
+	}
+	if err != nil {
+		cs.finishCallSpan(err)
+	}
+	return err
+}
+
+// finishCallSpan finishes the call span at most once, deriving tagCode from
+// the stream's final status (io.EOF signals a clean close, so it is not
+// itself reported as an error). It may be called concurrently from
+// SendMsg/RecvMsg and the ctx.Done() watcher goroutine; only the first caller
+// finishes the span.
+func (cs *clientStream) finishCallSpan(err error) {
+	cs.mu.Lock()
+	if cs.finished {
+		cs.mu.Unlock()
+		return
+	}
+	cs.finished = true
+	span := cs.callSpan
+	cs.callSpan = nil
+	cs.mu.Unlock()
+
+	if span == nil {
+		return
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	finishClientSpan(span, err)
+}
+
+func (cs *clientStream) traceMessage(err error) {
+	span, _ := tracer.StartSpanFromContext(cs.ClientStream.Context(), "grpc.message",
+		tracer.ServiceName(cs.cfg.serviceName),
+		tracer.ResourceName(cs.method),
+		tracer.SpanType(ext.AppTypeRPC),
+		tracer.Tag(tagMethod, cs.method),
+	)
+	if err != nil && err != io.EOF {
+		span.SetTag(ext.Error, err)
+	}
+	span.Finish()
+}
+
+// UnaryServerInterceptor will add tracing to a server unary handler.
+func UnaryServerInterceptor(opts ...InterceptorOption) grpc.UnaryServerInterceptor {
+	cfg := new(interceptorConfig)
+	defaults(cfg)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		span, ctx := startServerSpan(ctx, cfg, info.FullMethod)
+		resp, err = handler(ctx, req)
+		finishServerSpan(span, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor will add tracing to a server streaming handler.
+func StreamServerInterceptor(opts ...InterceptorOption) grpc.StreamServerInterceptor {
+	cfg := new(interceptorConfig)
+	defaults(cfg)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		span, ctx := startServerSpan(ss.Context(), cfg, info.FullMethod)
+		ss = &serverStream{
+			ServerStream: ss,
+			ctx:          ctx,
+			cfg:          cfg,
+			method:       info.FullMethod,
+		}
+		err := handler(srv, ss)
+		finishServerSpan(span, err)
+		return err
+	}
+}
+
+// startServerSpan starts the "grpc.server" span, extracting the propagated
+// span context, if any, from the incoming metadata so the server span
+// becomes a child of the calling client's span.
+func startServerSpan(ctx context.Context, cfg *interceptorConfig, method string) (ddtrace.Span, context.Context) {
+	opts := []ddtrace.StartSpanOption{
+		tracer.ServiceName(cfg.serviceName),
+		tracer.ResourceName(method),
+		tracer.SpanType(ext.AppTypeRPC),
+		tracer.Tag(tagMethod, method),
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if spanctx, err := tracer.Extract(metadataCarrier{md}); err == nil {
+		opts = append(opts, tracer.ChildOf(spanctx))
+	}
+	span, ctx := tracer.StartSpanFromContext(ctx, "grpc.server", opts...)
+	tagMetadata(span, cfg.metadataTags, md)
+	if cfg.peerTags {
+		tagPeer(span, ctx)
+	}
+	return span, ctx
+}
+
+// peerHostnameLookupTimeout bounds the reverse-DNS lookup tagPeer performs
+// for WithPeerTags, so a slow or unreachable resolver can't stall a request
+// handler for anywhere near the OS's default lookup timeout.
+const peerHostnameLookupTimeout = 50 * time.Millisecond
+
+// tagPeer records the connecting peer's address and, when it resolves within
+// peerHostnameLookupTimeout, hostname on span.
+func tagPeer(span ddtrace.Span, ctx context.Context) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return
+	}
+	addr := p.Addr.String()
+	span.SetTag(tagPeerAddress, addr)
+	host, _ := splitHostPort(addr)
+	if host == "" {
+		return
+	}
+	lookupCtx, cancel := context.WithTimeout(context.Background(), peerHostnameLookupTimeout)
+	defer cancel()
+	if names, err := net.DefaultResolver.LookupAddr(lookupCtx, host); err == nil && len(names) > 0 {
+		span.SetTag(tagPeerHostname, strings.TrimSuffix(names[0], "."))
+	}
+}
+
+func finishServerSpan(span ddtrace.Span, err error) {
+	code := status.Code(err)
+	span.SetTag(tagCode, code.String())
+	if err != nil {
+		span.SetTag(ext.Error, err)
+	}
+	span.Finish()
+}
+
+// serverStream wraps a grpc.ServerStream to trace per-message Send/Recv
+// calls and to carry the extracted context to the handler.
+type serverStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	cfg    *interceptorConfig
+	method string
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+func (ss *serverStream) SendMsg(m interface{}) error {
+	err := ss.ServerStream.SendMsg(m)
+	if ss.cfg.traceStreamMessages {
+		ss.traceMessage(err)
+	}
+	return err
+}
+
+func (ss *serverStream) RecvMsg(m interface{}) error {
+	err := ss.ServerStream.RecvMsg(m)
+	if ss.cfg.traceStreamMessages {
+		ss.traceMessage(err)
+	}
+	return err
+}
+
+func (ss *serverStream) traceMessage(err error) {
+	span, _ := tracer.StartSpanFromContext(ss.ctx, "grpc.message",
+		tracer.ServiceName(ss.cfg.serviceName),
+		tracer.ResourceName(ss.method),
+		tracer.SpanType(ext.AppTypeRPC),
+		tracer.Tag(tagMethod, ss.method),
+	)
+	if err != nil && err != io.EOF {
+		span.SetTag(ext.Error, err)
+	}
+	span.Finish()
+}
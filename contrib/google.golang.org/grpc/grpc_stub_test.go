@@ -0,0 +1,417 @@
+package grpc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	ddgrpc "gopkg.in/DataDog/dd-trace-go.v1/contrib/google.golang.org/grpc"
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/google.golang.org/grpc/internal/fixture"
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/google.golang.org/grpc/internal/stubserver"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/stretchr/testify/assert"
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const tagCode = "grpc.code"
+
+func TestClient(t *testing.T) {
+	assert := assert.New(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ss := new(stubserver.StubServer)
+	assert.NoError(ss.Start(ddgrpc.WithServiceName("grpc")))
+	defer ss.Stop()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "a", tracer.ServiceName("b"), tracer.ResourceName("c"))
+
+	resp, err := ss.Client.Ping(ctx, &fixture.FixtureRequest{Name: "pass"})
+	assert.Nil(err)
+	span.Finish()
+	assert.Equal(resp.Message, "passed")
+
+	spans := mt.FinishedSpans()
+	assert.Len(spans, 3)
+
+	var serverSpan, clientSpan, rootSpan mocktracer.Span
+	for _, s := range spans {
+		switch s.OperationName() {
+		case "grpc.server":
+			serverSpan = s
+		case "grpc.client":
+			clientSpan = s
+		case "a":
+			rootSpan = s
+		}
+	}
+
+	assert.NotNil(serverSpan)
+	assert.NotNil(clientSpan)
+	assert.NotNil(rootSpan)
+
+	assert.Equal(clientSpan.Tag(tagCode), codes.OK.String())
+	assert.Equal(clientSpan.TraceID(), rootSpan.TraceID())
+	assert.Equal(serverSpan.Tag(ext.ServiceName), "grpc")
+	assert.Equal(serverSpan.Tag(ext.ResourceName), "/grpc.Fixture/Ping")
+	assert.Equal(serverSpan.TraceID(), rootSpan.TraceID())
+}
+
+// streamPingMethod is the FullMethod the fixture service's StreamPing RPC is
+// registered under, and so the resource/grpc.method tag every span in
+// TestStreaming carries.
+const streamPingMethod = "/grpc.Fixture/StreamPing"
+
+func TestStreaming(t *testing.T) {
+	runPings := func(t *testing.T, ctx context.Context, client fixture.FixtureClient) {
+		stream, err := client.StreamPing(ctx)
+		assert.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, stream.Send(&fixture.FixtureRequest{Name: "pass"}))
+			resp, err := stream.Recv()
+			assert.NoError(t, err)
+			assert.Equal(t, resp.Message, "pass")
+		}
+		stream.CloseSend()
+		stream.Recv()
+	}
+
+	// checkStreamSpans asserts the tags common to every span runPings
+	// produces (resource name, grpc.method, service, span type), and returns
+	// the client and server call spans for callers that want to dig further.
+	checkStreamSpans := func(t *testing.T, spans []mocktracer.Span) (clientCall, serverCall mocktracer.Span) {
+		for _, s := range spans {
+			if s.OperationName() == "a" {
+				continue
+			}
+			assert.Equal(t, streamPingMethod, s.Tag(ext.ResourceName))
+			assert.Equal(t, streamPingMethod, s.Tag(tagMethod))
+			assert.Equal(t, "grpc", s.Tag(ext.ServiceName))
+			assert.Equal(t, ext.AppTypeRPC, s.Tag(ext.SpanType))
+			switch s.OperationName() {
+			case "grpc.client":
+				clientCall = s
+			case "grpc.server":
+				serverCall = s
+			}
+		}
+		assert.NotNil(t, clientCall, "missing grpc.client call span")
+		assert.NotNil(t, serverCall, "missing grpc.server call span")
+		return clientCall, serverCall
+	}
+
+	t.Run("All", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		ss := new(stubserver.StubServer)
+		assert.NoError(t, ss.Start(ddgrpc.WithServiceName("grpc")))
+		defer ss.Stop()
+
+		span, ctx := tracer.StartSpanFromContext(context.Background(), "a",
+			tracer.ServiceName("b"), tracer.ResourceName("c"))
+
+		runPings(t, ctx, ss.Client)
+		span.Finish()
+
+		// the call and message spans are finished synchronously as the
+		// RPC returns, so there's no need to poll for them to show up.
+		// 1 root span, 1 client call, 1 server call, plus a "grpc.message"
+		// span for every Send/RecvMsg on both ends of the stream: the client
+		// sends 2 and receives 3 (2 replies + the final EOF), and the server
+		// mirrors that with 3 receives (2 messages + the closing EOF) and 2
+		// sends.
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 13)
+
+		clientCall, serverCall := checkStreamSpans(t, spans)
+		assert.Equal(t, codes.OK.String(), clientCall.Tag(tagCode))
+		assert.Equal(t, "127.0.0.1", clientCall.Tag(ext.TargetHost))
+		assert.NotEmpty(t, clientCall.Tag(ext.TargetPort))
+		assert.Equal(t, codes.OK.String(), serverCall.Tag(tagCode))
+	})
+
+	t.Run("CallsOnly", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		ss := new(stubserver.StubServer)
+		assert.NoError(t, ss.Start(ddgrpc.WithServiceName("grpc"), ddgrpc.WithStreamMessages(false)))
+		defer ss.Stop()
+
+		span, ctx := tracer.StartSpanFromContext(context.Background(), "a",
+			tracer.ServiceName("b"), tracer.ResourceName("c"))
+
+		runPings(t, ctx, ss.Client)
+		span.Finish()
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 3, "expected 1 server call + 1 client call + 1 parent ctx, but got %v", len(spans))
+		checkStreamSpans(t, spans)
+	})
+
+	t.Run("MessagesOnly", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		ss := new(stubserver.StubServer)
+		assert.NoError(t, ss.Start(ddgrpc.WithServiceName("grpc"), ddgrpc.WithStreamCalls(false)))
+		defer ss.Stop()
+
+		span, ctx := tracer.StartSpanFromContext(context.Background(), "a",
+			tracer.ServiceName("b"), tracer.ResourceName("c"))
+
+		runPings(t, ctx, ss.Client)
+		span.Finish()
+
+		// WithStreamCalls(false) only changes the client call span's
+		// lifetime: instead of lasting the whole stream it's finished the
+		// moment the stream opens, with a hardcoded OK status, rather than
+		// being left out of the trace. The server call span and all the
+		// per-message spans are unaffected, so the total is unchanged from
+		// "All".
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 13)
+
+		clientCall, serverCall := checkStreamSpans(t, spans)
+		assert.Equal(t, codes.OK.String(), clientCall.Tag(tagCode), "a disabled call span always reports OK, since it finishes before the stream's real outcome is known")
+		assert.Equal(t, codes.OK.String(), serverCall.Tag(tagCode))
+	})
+}
+
+func TestChild(t *testing.T) {
+	assert := assert.New(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ss := &stubserver.StubServer{
+		NoClientInterceptor: true,
+		PingF: func(ctx context.Context, in *fixture.FixtureRequest) (*fixture.FixtureReply, error) {
+			span, _ := tracer.StartSpanFromContext(ctx, "child")
+			span.Finish()
+			return &fixture.FixtureReply{Message: "child"}, nil
+		},
+	}
+	assert.NoError(ss.Start(ddgrpc.WithServiceName("grpc")))
+	defer ss.Stop()
+
+	resp, err := ss.Client.Ping(context.Background(), &fixture.FixtureRequest{Name: "child"})
+	assert.Nil(err)
+	assert.Equal(resp.Message, "child")
+
+	spans := mt.FinishedSpans()
+	assert.Len(spans, 2)
+
+	var serverSpan, childSpan mocktracer.Span
+	for _, s := range spans {
+		switch s.OperationName() {
+		case "grpc.server":
+			serverSpan = s
+		case "child":
+			childSpan = s
+		}
+	}
+
+	assert.NotNil(childSpan)
+	assert.Nil(childSpan.Tag(ext.Error))
+	assert.NotNil(serverSpan)
+	assert.Nil(serverSpan.Tag(ext.Error))
+	assert.Equal(serverSpan.Tag(ext.ResourceName), "/grpc.Fixture/Ping")
+}
+
+func TestPass(t *testing.T) {
+	assert := assert.New(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ss := &stubserver.StubServer{NoClientInterceptor: true}
+	assert.NoError(ss.Start(ddgrpc.WithServiceName("grpc")))
+	defer ss.Stop()
+
+	resp, err := ss.Client.Ping(context.Background(), &fixture.FixtureRequest{Name: "pass"})
+	assert.Nil(err)
+	assert.Equal(resp.Message, "passed")
+
+	spans := mt.FinishedSpans()
+	assert.Len(spans, 1)
+
+	s := spans[0]
+	assert.Nil(s.Tag(ext.Error))
+	assert.Equal(s.OperationName(), "grpc.server")
+	assert.Equal(s.Tag(ext.ServiceName), "grpc")
+	assert.Equal(s.Tag(ext.SpanType), ext.AppTypeRPC)
+}
+
+func TestDeadlineExceeded(t *testing.T) {
+	assert := assert.New(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ss := &stubserver.StubServer{
+		PingF: func(ctx context.Context, in *fixture.FixtureRequest) (*fixture.FixtureReply, error) {
+			<-ctx.Done()
+			return nil, status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+		},
+	}
+	assert.NoError(ss.Start(ddgrpc.WithServiceName("grpc")))
+	defer ss.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := ss.Client.Ping(ctx, &fixture.FixtureRequest{Name: "pass"})
+	assert.Error(err)
+
+	spans := mt.FinishedSpans()
+	var clientSpan mocktracer.Span
+	for _, s := range spans {
+		if s.OperationName() == "grpc.client" {
+			clientSpan = s
+		}
+	}
+	assert.NotNil(clientSpan)
+	assert.Equal(codes.DeadlineExceeded.String(), clientSpan.Tag(tagCode))
+	assert.NotNil(clientSpan.Tag(ext.Error))
+}
+
+func TestStreamCancellation(t *testing.T) {
+	assert := assert.New(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	block := make(chan struct{})
+	ss := &stubserver.StubServer{
+		StreamPingF: func(stream fixture.Fixture_StreamPingServer) error {
+			<-block
+			return nil
+		},
+	}
+	assert.NoError(ss.Start(ddgrpc.WithServiceName("grpc")))
+	defer ss.Stop()
+	defer close(block)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := ss.Client.StreamPing(ctx)
+	assert.NoError(err)
+	assert.NoError(stream.Send(&fixture.FixtureRequest{Name: "pass"}))
+
+	// Cancel and then abandon the stream, without calling Send/Recv again,
+	// the way a caller that gives up on a stream typically would. Nothing
+	// but the ctx.Done() watcher started in StreamClientInterceptor can
+	// finish the call span in this case.
+	cancel()
+
+	assert.Eventually(func() bool {
+		for _, s := range mt.FinishedSpans() {
+			if s.OperationName() == "grpc.client" {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond, "call span was not finished after context cancellation")
+
+	var clientSpan mocktracer.Span
+	for _, s := range mt.FinishedSpans() {
+		if s.OperationName() == "grpc.client" {
+			clientSpan = s
+		}
+	}
+	assert.NotNil(clientSpan)
+	assert.Equal(codes.Canceled.String(), clientSpan.Tag(tagCode))
+}
+
+func TestServerPanicRecovered(t *testing.T) {
+	assert := assert.New(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ss := &stubserver.StubServer{
+		PingF: func(ctx context.Context, in *fixture.FixtureRequest) (*fixture.FixtureReply, error) {
+			panic(errors.New("boom"))
+		},
+	}
+	assert.NoError(ss.Start(ddgrpc.WithServiceName("grpc")))
+	defer ss.Stop()
+
+	_, err := ss.Client.Ping(context.Background(), &fixture.FixtureRequest{Name: "pass"})
+	assert.Error(err)
+	assert.Equal(codes.Internal, status.Code(err))
+
+	spans := mt.FinishedSpans()
+	var serverSpan mocktracer.Span
+	for _, s := range spans {
+		if s.OperationName() == "grpc.server" {
+			serverSpan = s
+		}
+	}
+	assert.NotNil(serverSpan)
+	assert.NotNil(serverSpan.Tag(ext.Error))
+}
+
+func TestCustomTrailer(t *testing.T) {
+	assert := assert.New(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ss := &stubserver.StubServer{
+		StreamPingF: func(stream fixture.Fixture_StreamPingServer) error {
+			stream.SetTrailer(metadata.Pairs("x-custom-trailer", "hello"))
+			return nil
+		},
+	}
+	assert.NoError(ss.Start(ddgrpc.WithServiceName("grpc")))
+	defer ss.Stop()
+
+	stream, err := ss.Client.StreamPing(context.Background())
+	assert.NoError(err)
+	_, err = stream.Recv()
+	assert.Error(err)
+
+	assert.Equal([]string{"hello"}, stream.Trailer().Get("x-custom-trailer"))
+}
+
+func TestMetadataAndPeerTags(t *testing.T) {
+	assert := assert.New(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ss := new(stubserver.StubServer)
+	assert.NoError(ss.Start(
+		ddgrpc.WithServiceName("grpc"),
+		ddgrpc.WithMetadataTags("x-request-id", "authorization"),
+		ddgrpc.WithPeerTags(true),
+	))
+	defer ss.Stop()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "abc-123", "authorization", "Bearer secret")
+	_, err := ss.Client.Ping(ctx, &fixture.FixtureRequest{Name: "pass"})
+	assert.NoError(err)
+
+	spans := mt.FinishedSpans()
+	var serverSpan, clientSpan mocktracer.Span
+	for _, s := range spans {
+		switch s.OperationName() {
+		case "grpc.server":
+			serverSpan = s
+		case "grpc.client":
+			clientSpan = s
+		}
+	}
+
+	assert.NotNil(serverSpan)
+	assert.NotNil(clientSpan)
+
+	assert.Equal("abc-123", clientSpan.Tag("grpc.metadata.x-request-id"))
+	assert.Equal("abc-123", serverSpan.Tag("grpc.metadata.x-request-id"))
+	assert.Nil(clientSpan.Tag("grpc.metadata.authorization"))
+	assert.Nil(serverSpan.Tag("grpc.metadata.authorization"))
+
+	assert.NotNil(serverSpan.Tag("peer.address"))
+}
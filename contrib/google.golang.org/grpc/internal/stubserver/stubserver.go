@@ -0,0 +1,132 @@
+// Package stubserver provides a reusable grpc.Fixture client/server pair
+// whose per-RPC behavior is supplied by the caller, modeled on grpc-go's own
+// internal stubserver test helper. It lets contrib/google.golang.org/grpc
+// tests exercise the tracing interceptors against arbitrary server
+// behavior (errors, panics, delays, custom trailers, ...) without each test
+// hand-rolling its own fixture service.
+package stubserver
+
+import (
+	"io"
+	"net"
+
+	ddgrpc "gopkg.in/DataDog/dd-trace-go.v1/contrib/google.golang.org/grpc"
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/google.golang.org/grpc/internal/fixture"
+
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StubServer is a fixture.FixtureServer whose behavior is provided by the
+// PingF and StreamPingF hooks. A nil hook falls back to the default
+// echo-style behavior used throughout the package's tests.
+type StubServer struct {
+	PingF       func(ctx context.Context, in *fixture.FixtureRequest) (*fixture.FixtureReply, error)
+	StreamPingF func(stream fixture.Fixture_StreamPingServer) error
+
+	// NoClientInterceptor, when set, leaves the client connection
+	// untraced so tests can assert on child-span behavior without a
+	// client-side span in the picture.
+	NoClientInterceptor bool
+
+	listener net.Listener
+	server   *grpc.Server
+	conn     *grpc.ClientConn
+
+	// Client is populated by Start and is ready to use once it returns.
+	Client fixture.FixtureClient
+}
+
+var _ fixture.FixtureServer = (*StubServer)(nil)
+
+// Ping implements fixture.FixtureServer, recovering panics from PingF into a
+// codes.Internal error so that a misbehaving test case doesn't take down the
+// whole test binary.
+func (ss *StubServer) Ping(ctx context.Context, in *fixture.FixtureRequest) (reply *fixture.FixtureReply, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = status.Errorf(codes.Internal, "panic: %v", r)
+		}
+	}()
+	if ss.PingF != nil {
+		return ss.PingF(ctx, in)
+	}
+	return &fixture.FixtureReply{Message: "passed"}, nil
+}
+
+// StreamPing implements fixture.FixtureServer.
+func (ss *StubServer) StreamPing(stream fixture.Fixture_StreamPingServer) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = status.Errorf(codes.Internal, "panic: %v", r)
+		}
+	}()
+	if ss.StreamPingF != nil {
+		return ss.StreamPingF(stream)
+	}
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&fixture.FixtureReply{Message: msg.Name}); err != nil {
+			return err
+		}
+	}
+}
+
+// Start spins up the listener, server and client connection, wiring in the
+// datadog gRPC interceptors configured by interceptorOpts.
+func (ss *StubServer) Start(interceptorOpts ...ddgrpc.InterceptorOption) error {
+	ss.server = grpc.NewServer(
+		grpc.UnaryInterceptor(ddgrpc.UnaryServerInterceptor(interceptorOpts...)),
+		grpc.StreamInterceptor(ddgrpc.StreamServerInterceptor(interceptorOpts...)),
+	)
+	fixture.RegisterFixtureServer(ss.server, ss)
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	ss.listener = li
+	go ss.server.Serve(li)
+
+	dialOpts := []grpc.DialOption{grpc.WithInsecure()}
+	if !ss.NoClientInterceptor {
+		dialOpts = append(dialOpts,
+			grpc.WithUnaryInterceptor(ddgrpc.UnaryClientInterceptor(interceptorOpts...)),
+			grpc.WithStreamInterceptor(ddgrpc.StreamClientInterceptor(interceptorOpts...)),
+		)
+	}
+	conn, err := grpc.Dial(li.Addr().String(), dialOpts...)
+	if err != nil {
+		ss.Stop()
+		return err
+	}
+	ss.conn = conn
+	ss.Client = fixture.NewFixtureClient(conn)
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (ss *StubServer) Addr() string {
+	return ss.listener.Addr().String()
+}
+
+// Stop tears down the client connection, server and listener.
+func (ss *StubServer) Stop() {
+	if ss.conn != nil {
+		ss.conn.Close()
+	}
+	if ss.server != nil {
+		ss.server.Stop()
+	}
+	if ss.listener != nil {
+		ss.listener.Close()
+	}
+}
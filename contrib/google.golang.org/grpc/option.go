@@ -0,0 +1,85 @@
+package grpc
+
+import "strings"
+
+// redactedMetadataTags lists metadata keys that are never recorded as span
+// tags, regardless of what is passed to WithMetadataTags, since they
+// typically carry credentials rather than routing/correlation info.
+var redactedMetadataTags = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+}
+
+// interceptorConfig holds the configuration for the gRPC integration. It is
+// populated by applying the InterceptorOption values passed to the
+// interceptor constructors.
+type interceptorConfig struct {
+	serviceName         string
+	traceStreamCalls    bool
+	traceStreamMessages bool
+	metadataTags        []string
+	peerTags            bool
+}
+
+// InterceptorOption represents an option that can be passed to the
+// interceptor constructors exported by this package.
+type InterceptorOption func(*interceptorConfig)
+
+func defaults(cfg *interceptorConfig) {
+	cfg.serviceName = "grpc"
+	cfg.traceStreamCalls = true
+	cfg.traceStreamMessages = true
+}
+
+// WithServiceName sets the given service name for the intercepted client.
+func WithServiceName(name string) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.serviceName = name
+	}
+}
+
+// WithStreamCalls controls how long the client call span of a streaming RPC
+// lasts. When enabled, it lasts the duration of the whole stream and carries
+// the stream's final status. When disabled, the client call span is still
+// recorded, but it is finished as soon as the stream opens and always tagged
+// OK. The server call span is not affected by this option.
+func WithStreamCalls(enabled bool) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.traceStreamCalls = enabled
+	}
+}
+
+// WithStreamMessages enables or disables tracing of streaming messages. This
+// tracks each message sent or received as its own span.
+func WithStreamMessages(enabled bool) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.traceStreamMessages = enabled
+	}
+}
+
+// WithMetadataTags specifies gRPC metadata keys whose values should be
+// recorded as "grpc.metadata.<key>" tags on the client and server spans,
+// taken from the outgoing and incoming metadata respectively. Well-known
+// secret-carrying headers ("authorization", "cookie") are always redacted,
+// even if listed here.
+func WithMetadataTags(keys ...string) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		for _, key := range keys {
+			if redactedMetadataTags[strings.ToLower(key)] {
+				continue
+			}
+			cfg.metadataTags = append(cfg.metadataTags, key)
+		}
+	}
+}
+
+// WithPeerTags enables or disables recording the client's peer.address and
+// peer.hostname on the server span. The hostname is resolved with a reverse
+// DNS lookup bounded by peerHostnameLookupTimeout, so a slow or unreachable
+// resolver costs each RPC at most that long rather than hanging the handler;
+// the tag is simply omitted if the lookup doesn't finish in time.
+func WithPeerTags(enabled bool) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.peerTags = enabled
+	}
+}
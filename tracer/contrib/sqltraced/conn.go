@@ -0,0 +1,137 @@
+package sqltraced
+
+import (
+	"database/sql/driver"
+	"strings"
+
+	"github.com/DataDog/dd-trace-go/tracer"
+)
+
+// traceParams holds everything needed to name and tag a query span; it's
+// shared, read-only, across the driver/conn/stmt chain for a given DB.
+type traceParams struct {
+	tracer     *tracer.Tracer
+	service    string
+	driverName string
+	cfg        *traceConfig
+	conn       connParams
+}
+
+// newSpan starts a "<driver>.query" span for query, tagged with the
+// connection metadata and, once parseable, the normalized operation and
+// resource name.
+func (tp *traceParams) newSpan(query string) *tracer.Span {
+	operation, resource := parseQuery(query)
+
+	span := tracer.NewSpan(strings.ToLower(tp.driverName)+".query", tp.service, resource, tp.tracer)
+	span.Type = "sql"
+	if tp.conn.user != "" {
+		span.SetMeta("db.user", tp.conn.user)
+	}
+	if tp.conn.host != "" {
+		span.SetMeta("out.host", tp.conn.host)
+	}
+	if tp.conn.port != "" {
+		span.SetMeta("out.port", tp.conn.port)
+	}
+	if tp.conn.name != "" {
+		span.SetMeta("db.name", tp.conn.name)
+	}
+	if operation != "" {
+		span.SetMeta("sql.operation", operation)
+	}
+
+	statement := query
+	if tp.cfg.obfuscate {
+		statement = obfuscate(statement)
+	}
+	span.SetMeta("db.statement", statement)
+
+	return span
+}
+
+func (tp *traceParams) finishSpan(span *tracer.Span, res driver.Result, err error) {
+	if err != nil {
+		span.SetError(err)
+	} else if res != nil {
+		if n, rerr := res.RowsAffected(); rerr == nil {
+			span.SetMetric("sql.rows_affected", float64(n))
+		}
+	}
+	span.Finish()
+}
+
+// tracedDriver wraps a driver.Driver so that every connection it opens is
+// traced.
+type tracedDriver struct {
+	driver.Driver
+	params traceParams
+}
+
+func (d *tracedDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn, params: d.params}, nil
+}
+
+// tracedConn wraps a driver.Conn, tracing every statement prepared against
+// it.
+type tracedConn struct {
+	driver.Conn
+	params traceParams
+}
+
+func (c *tracedConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{Stmt: stmt, params: c.params, query: query}, nil
+}
+
+func (c *tracedConn) Begin() (driver.Tx, error) {
+	tx, err := c.Conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &tracedTx{Tx: tx, params: c.params}, nil
+}
+
+// tracedStmt wraps a driver.Stmt, tracing every Exec/Query run against it.
+type tracedStmt struct {
+	driver.Stmt
+	params traceParams
+	query  string
+}
+
+func (s *tracedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	span := s.params.newSpan(s.query)
+	res, err := s.Stmt.Exec(args)
+	s.params.finishSpan(span, res, err)
+	return res, err
+}
+
+func (s *tracedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	span := s.params.newSpan(s.query)
+	rows, err := s.Stmt.Query(args)
+	s.params.finishSpan(span, nil, err)
+	return rows, err
+}
+
+// tracedTx wraps a driver.Tx. Commit and Rollback pass straight through and
+// are not traced; statements run within the transaction are prepared on the
+// tracedConn and traced like any other.
+type tracedTx struct {
+	driver.Tx
+	params traceParams
+}
+
+func (tx *tracedTx) Commit() error {
+	return tx.Tx.Commit()
+}
+
+func (tx *tracedTx) Rollback() error {
+	return tx.Tx.Rollback()
+}
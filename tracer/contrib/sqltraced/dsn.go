@@ -0,0 +1,48 @@
+package sqltraced
+
+import (
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// connParams carries the connection metadata recorded as tags on every
+// query span, so it only needs to be parsed out of the dsn once, at NewDB
+// time, rather than on every query.
+type connParams struct {
+	user string
+	host string
+	port string
+	name string
+}
+
+// parseDSN extracts connParams from dsn for the given driverName. Drivers
+// this package doesn't know how to parse yield a zero connParams rather than
+// an error, since the connection tags are a nice-to-have, not essential to
+// tracing the query itself.
+func parseDSN(driverName, dsn string) connParams {
+	switch driverName {
+	case "MySQL", "mysql":
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return connParams{}
+		}
+		host, port := splitHostPort(cfg.Addr)
+		return connParams{
+			user: cfg.User,
+			host: host,
+			port: port,
+			name: cfg.DBName,
+		}
+	default:
+		return connParams{}
+	}
+}
+
+func splitHostPort(addr string) (host, port string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, ""
+	}
+	return host, port
+}
@@ -29,3 +29,19 @@ func TestMySQL(t *testing.T) {
 	// Testing MySQL driver
 	AllSQLTests(t, db, expectedSpan)
 }
+
+func TestMySQLQueryObfuscationDisabled(t *testing.T) {
+	dsn := "ubuntu@tcp(127.0.0.1:3306)/circle_test"
+	db := NewDB("MySQL", "mysql-test", &mysql.MySQLDriver{}, dsn, WithQueryObfuscation(false))
+	defer db.Close()
+
+	if _, err := db.Exec("INSERT INTO sqltraced_test (id, name) VALUES (42, 'alice')"); err != nil {
+		t.Fatalf("exec failed: %s", err)
+	}
+
+	spans := db.FinishedSpans()
+	last := spans[len(spans)-1]
+	if got := last.Meta["db.statement"]; got != "INSERT INTO sqltraced_test (id, name) VALUES (42, 'alice')" {
+		t.Fatalf("expected db.statement to be left unobfuscated, got %q", got)
+	}
+}
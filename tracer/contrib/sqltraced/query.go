@@ -0,0 +1,83 @@
+package sqltraced
+
+import (
+	"regexp"
+	"strings"
+)
+
+// verbRE extracts the leading statement verb.
+var verbRE = regexp.MustCompile(`(?i)^\s*([a-zA-Z]+)\b`)
+
+const identPattern = "`?\"?([a-zA-Z0-9_.]+)`?\"?"
+
+// ifExistsPattern optionally consumes "IF EXISTS"/"IF NOT EXISTS" between a
+// DDL verb's TABLE keyword and the table name, so it isn't mistaken for the
+// identifier itself.
+const ifExistsPattern = `(?:IF\s+(?:NOT\s+)?EXISTS\s+)?`
+
+// tableAfterRE finds the table name that follows the keyword a given verb
+// names its target with: UPDATE names it directly, SELECT/DELETE via FROM,
+// INSERT via INTO, and the DDL verbs via TABLE (optionally followed by IF
+// [NOT] EXISTS).
+var tableAfterRE = map[string]*regexp.Regexp{
+	"SELECT":   regexp.MustCompile(`(?i)\bFROM\s+` + identPattern),
+	"DELETE":   regexp.MustCompile(`(?i)\bFROM\s+` + identPattern),
+	"INSERT":   regexp.MustCompile(`(?i)\bINTO\s+` + identPattern),
+	"UPDATE":   regexp.MustCompile(`(?i)^\s*UPDATE\s+` + identPattern),
+	"CREATE":   regexp.MustCompile(`(?i)\bTABLE\s+` + ifExistsPattern + identPattern),
+	"ALTER":    regexp.MustCompile(`(?i)\bTABLE\s+` + ifExistsPattern + identPattern),
+	"DROP":     regexp.MustCompile(`(?i)\bTABLE\s+` + ifExistsPattern + identPattern),
+	"TRUNCATE": regexp.MustCompile(`(?i)\bTABLE\s+` + ifExistsPattern + identPattern),
+}
+
+// ddlOperations groups the statement verbs reported as the generic "DDL"
+// sql.operation, rather than their literal verb.
+var ddlOperations = map[string]bool{
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true,
+}
+
+// parseQuery returns the normalized sql.operation (SELECT/INSERT/UPDATE/
+// DELETE/DDL) and the resource name (e.g. "SELECT users") for query. When
+// the statement's verb isn't recognized, it falls back to the raw query as
+// the resource and an empty operation.
+func parseQuery(query string) (operation, resource string) {
+	vm := verbRE.FindStringSubmatch(query)
+	if vm == nil {
+		return "", query
+	}
+	verb := strings.ToUpper(vm[1])
+
+	tableRE, ok := tableAfterRE[verb]
+	if !ok {
+		return "", query
+	}
+	tm := tableRE.FindStringSubmatch(query)
+	if tm == nil {
+		return "", query
+	}
+
+	operation = verb
+	if ddlOperations[verb] {
+		operation = "DDL"
+	}
+	return operation, verb + " " + tm[1]
+}
+
+// literalRE matches single/double-quoted string literals, numeric literals,
+// and existing bind placeholders ("$1", ":name"). Placeholders are matched
+// here, rather than left to fall through, only so obfuscate can recognize
+// and skip them instead of mistaking the digits in "$1" for a numeric
+// literal.
+var literalRE = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|\$\d+\b|:[a-zA-Z_]\w*\b|\b\d+\.?\d*\b`)
+
+// obfuscate replaces literal values in query with "?", leaving existing
+// bind placeholders ("?", "$1", ":name", ...) untouched, so that db.statement
+// doesn't leak user data into span tags.
+func obfuscate(query string) string {
+	return literalRE.ReplaceAllStringFunc(query, func(m string) string {
+		if strings.HasPrefix(m, "$") || strings.HasPrefix(m, ":") {
+			return m
+		}
+		return "?"
+	})
+}
@@ -0,0 +1,36 @@
+package sqltraced
+
+import "testing"
+
+func TestParseQuery(t *testing.T) {
+	for _, tt := range []struct {
+		query             string
+		operation, resource string
+	}{
+		{"SELECT * FROM users WHERE id = 1", "SELECT", "SELECT users"},
+		{"INSERT INTO users (id) VALUES (1)", "INSERT", "INSERT users"},
+		{"UPDATE users SET name = 'x' WHERE id = 1", "UPDATE", "UPDATE users"},
+		{"DELETE FROM users WHERE id = 1", "DELETE", "DELETE users"},
+		{"CREATE TABLE IF NOT EXISTS users (id integer)", "DDL", "CREATE users"},
+		{"DROP TABLE IF EXISTS users", "DDL", "DROP users"},
+	} {
+		operation, resource := parseQuery(tt.query)
+		if operation != tt.operation || resource != tt.resource {
+			t.Errorf("parseQuery(%q) = (%q, %q), want (%q, %q)",
+				tt.query, operation, resource, tt.operation, tt.resource)
+		}
+	}
+}
+
+func TestObfuscate(t *testing.T) {
+	for _, tt := range []struct{ query, want string }{
+		{"SELECT * FROM users WHERE id = 42", "SELECT * FROM users WHERE id = ?"},
+		{"SELECT * FROM users WHERE name = 'alice'", "SELECT * FROM users WHERE name = ?"},
+		{"SELECT * FROM users WHERE id = $1", "SELECT * FROM users WHERE id = $1"},
+		{"SELECT * FROM users WHERE id = :id", "SELECT * FROM users WHERE id = :id"},
+	} {
+		if got := obfuscate(tt.query); got != tt.want {
+			t.Errorf("obfuscate(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
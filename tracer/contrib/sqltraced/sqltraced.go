@@ -0,0 +1,82 @@
+// Package sqltraced provides a database/sql/driver wrapper that traces every
+// query it executes.
+package sqltraced
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/DataDog/dd-trace-go/tracer"
+)
+
+// traceConfig holds the options applied when tracing queries through a DB.
+type traceConfig struct {
+	obfuscate bool
+}
+
+// Option customizes the tracing behavior of a DB returned by NewDB.
+type Option func(*traceConfig)
+
+// WithQueryObfuscation enables or disables obfuscating literal values
+// (quoted strings, numeric literals) out of the db.statement tag. It
+// defaults to enabled, since queries often embed user data directly.
+func WithQueryObfuscation(enabled bool) Option {
+	return func(cfg *traceConfig) {
+		cfg.obfuscate = enabled
+	}
+}
+
+// DB wraps a *sql.DB so that every query run through it is automatically
+// traced.
+type DB struct {
+	*sql.DB
+	tracer *tracer.Tracer
+}
+
+// FinishedSpans returns the query spans recorded so far. It exists to let
+// tests inspect tracing behavior without reaching into the tracer package.
+func (db *DB) FinishedSpans() []*tracer.Span {
+	return db.tracer.FinishedSpans()
+}
+
+var driverRegistrations uint64
+
+// NewDB opens a traced connection to a SQL database. driverName identifies
+// the SQL dialect used to parse dsn and name queries (e.g. "mysql"); drv is
+// the underlying database/sql/driver.Driver implementation; service names
+// the spans produced for queries run against the returned DB.
+func NewDB(driverName, service string, drv driver.Driver, dsn string, opts ...Option) *DB {
+	cfg := &traceConfig{obfuscate: true}
+	for _, fn := range opts {
+		fn(cfg)
+	}
+
+	t := tracer.NewTracer()
+	td := &tracedDriver{
+		Driver: drv,
+		params: traceParams{
+			tracer:     t,
+			service:    service,
+			driverName: driverName,
+			cfg:        cfg,
+			conn:       parseDSN(driverName, dsn),
+		},
+	}
+
+	// database/sql requires drivers to be registered under a unique name;
+	// since NewDB can be called more than once in a test binary, suffix the
+	// name to avoid a "sql: Register called twice" panic.
+	name := fmt.Sprintf("%s-dd-traced-%d", driverName, atomic.AddUint64(&driverRegistrations, 1))
+	sql.Register(name, td)
+
+	conn, err := sql.Open(name, dsn)
+	if err != nil {
+		// NewDB mirrors sql.Open's contract: dsn validation errors aside,
+		// this only fails if name wasn't registered above, which can't
+		// happen, so surface it loudly rather than returning a nil DB.
+		panic(err)
+	}
+	return &DB{DB: conn, tracer: t}
+}
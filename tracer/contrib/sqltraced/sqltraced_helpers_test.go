@@ -0,0 +1,101 @@
+package sqltraced
+
+import (
+	"testing"
+
+	"github.com/DataDog/dd-trace-go/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+// AllSQLTests runs the shared suite of query-tracing assertions against db,
+// so that each driver's *_test.go only needs to supply a *DB and the
+// connection tags it expects to see on every span. Being defined in a test
+// file, it is only visible to this package's own tests.
+func AllSQLTests(t *testing.T, db *DB, expectedSpan *tracer.Span) {
+	testExec(t, db, expectedSpan)
+	testQuery(t, db, expectedSpan)
+	testPrepareAndStmtExec(t, db, expectedSpan)
+	testTransaction(t, db, expectedSpan)
+}
+
+func testExec(t *testing.T, db *DB, expectedSpan *tracer.Span) {
+	assert := assert.New(t)
+
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS sqltraced_test (id integer, name varchar(255))")
+	assert.NoError(err)
+	_, err = db.Exec("INSERT INTO sqltraced_test (id, name) VALUES (42, 'alice')")
+	assert.NoError(err)
+
+	spans := db.FinishedSpans()
+	assert.True(len(spans) >= 2)
+
+	create := spans[len(spans)-2]
+	assertSpanTags(t, expectedSpan, create)
+	assert.Equal("CREATE sqltraced_test", create.Resource, "IF NOT EXISTS shouldn't be mistaken for the table name")
+	assert.Equal("DDL", create.Meta["sql.operation"])
+
+	insert := spans[len(spans)-1]
+	assertSpanTags(t, expectedSpan, insert)
+	assert.Equal("INSERT sqltraced_test", insert.Resource)
+	assert.Equal("INSERT", insert.Meta["sql.operation"])
+	assert.Equal(float64(1), insert.Metrics["sql.rows_affected"])
+	assert.NotContains(insert.Meta["db.statement"], "alice", "literal values should be obfuscated out of db.statement")
+}
+
+func testQuery(t *testing.T, db *DB, expectedSpan *tracer.Span) {
+	assert := assert.New(t)
+
+	rows, err := db.Query("SELECT id, name FROM sqltraced_test WHERE id = 42")
+	assert.NoError(err)
+	rows.Close()
+
+	spans := db.FinishedSpans()
+	last := spans[len(spans)-1]
+	assertSpanTags(t, expectedSpan, last)
+	assert.Equal("SELECT sqltraced_test", last.Resource)
+	assert.Equal("SELECT", last.Meta["sql.operation"])
+}
+
+func testPrepareAndStmtExec(t *testing.T, db *DB, expectedSpan *tracer.Span) {
+	assert := assert.New(t)
+
+	stmt, err := db.Prepare("UPDATE sqltraced_test SET name = ? WHERE id = ?")
+	assert.NoError(err)
+	defer stmt.Close()
+
+	_, err = stmt.Exec("bob", 42)
+	assert.NoError(err)
+
+	spans := db.FinishedSpans()
+	last := spans[len(spans)-1]
+	assertSpanTags(t, expectedSpan, last)
+	assert.Equal("UPDATE sqltraced_test", last.Resource)
+	assert.Equal("UPDATE", last.Meta["sql.operation"])
+}
+
+func testTransaction(t *testing.T, db *DB, expectedSpan *tracer.Span) {
+	assert := assert.New(t)
+
+	tx, err := db.Begin()
+	assert.NoError(err)
+
+	_, err = tx.Exec("DELETE FROM sqltraced_test WHERE id = 42")
+	assert.NoError(err)
+	assert.NoError(tx.Commit())
+
+	spans := db.FinishedSpans()
+	last := spans[len(spans)-1]
+	assertSpanTags(t, expectedSpan, last)
+	assert.Equal("DELETE sqltraced_test", last.Resource)
+	assert.Equal("DELETE", last.Meta["sql.operation"])
+}
+
+func assertSpanTags(t *testing.T, expectedSpan, gotSpan *tracer.Span) {
+	assert := assert.New(t)
+	assert.Equal(expectedSpan.Name, gotSpan.Name)
+	assert.Equal(expectedSpan.Service, gotSpan.Service)
+	assert.Equal(expectedSpan.Type, gotSpan.Type)
+	for k, v := range expectedSpan.Meta {
+		assert.Equal(v, gotSpan.Meta[k], "tag %s", k)
+	}
+}
@@ -0,0 +1,122 @@
+// Package tracer is the Datadog APM tracer client.
+//
+// NOTE: this file introduces the package's core Span/Tracer types so that
+// contrib/sqltraced has something to record spans against. It intentionally
+// stops short of agent submission: Tracer.record only buffers finished spans
+// in memory. If a production tracer core already exists elsewhere for this
+// import path, this file should be reconciled with (or replaced by) that
+// implementation rather than merged as-is.
+package tracer
+
+import (
+	"sync"
+	"time"
+)
+
+// Span represents a computation unit, such as a query or a request. Spans
+// are associated with a Tracer and are recorded once Finish is called.
+type Span struct {
+	Name     string
+	Service  string
+	Resource string
+	Type     string
+	Start    int64
+	Duration int64
+	Error    int32
+	Meta     map[string]string
+	Metrics  map[string]float64
+
+	tracer   *Tracer
+	finished bool
+	mu       sync.Mutex
+}
+
+// NewSpan creates a new Span that will be recorded on tracer once Finish is
+// called.
+func NewSpan(name, service, resource string, tracer *Tracer) *Span {
+	return &Span{
+		Name:     name,
+		Service:  service,
+		Resource: resource,
+		Start:    time.Now().UnixNano(),
+		Meta:     map[string]string{},
+		Metrics:  map[string]float64{},
+		tracer:   tracer,
+	}
+}
+
+// SetMeta attaches a string tag to the span.
+func (s *Span) SetMeta(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Meta == nil {
+		s.Meta = map[string]string{}
+	}
+	s.Meta[key] = value
+}
+
+// SetMetric attaches a numeric tag to the span.
+func (s *Span) SetMetric(key string, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Metrics == nil {
+		s.Metrics = map[string]float64{}
+	}
+	s.Metrics[key] = value
+}
+
+// SetError marks the span as failed and records err's message.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Error = 1
+	s.mu.Unlock()
+	s.SetMeta("error.msg", err.Error())
+}
+
+// Finish marks the span as complete and hands it to its tracer. Calling
+// Finish more than once has no effect.
+func (s *Span) Finish() {
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.Duration = time.Now().UnixNano() - s.Start
+	s.finished = true
+	t := s.tracer
+	s.mu.Unlock()
+
+	if t != nil {
+		t.record(s)
+	}
+}
+
+// Tracer buffers the spans recorded against it. It is safe for concurrent
+// use.
+type Tracer struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewTracer returns a new, empty Tracer.
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+func (t *Tracer) record(s *Span) {
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+}
+
+// FinishedSpans returns a copy of the spans recorded so far.
+func (t *Tracer) FinishedSpans() []*Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]*Span, len(t.spans))
+	copy(out, t.spans)
+	return out
+}